@@ -0,0 +1,91 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// BackupDB streams a consistent copy of db to w without blocking writers
+// for longer than it takes to start the snapshot transaction. It is safe to
+// call against a running pool.
+func BackupDB(ctx context.Context, db *bolt.DB, w io.Writer) error {
+	return db.View(func(tx *bolt.Tx) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
+
+// SnapshotDB writes a gzip-compressed backup of db to the provided path.
+func SnapshotDB(ctx context.Context, db *bolt.DB, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		desc := fmt.Sprintf("unable to create snapshot file %s", path)
+		return MakeError(ErrDBOpen, desc, err)
+	}
+	defer f.Close()
+
+	gw := gzip.NewWriter(f)
+	if err := BackupDB(ctx, db, gw); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// RunScheduledBackups periodically snapshots db to dir every interval,
+// keeping only the most recent retention snapshots, until ctx is canceled.
+// It is intended to be run in its own goroutine.
+func RunScheduledBackups(ctx context.Context, db *bolt.DB, dir string, interval time.Duration, retention int) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			name := fmt.Sprintf("backup-%d.gz", time.Now().Unix())
+			path := filepath.Join(dir, name)
+			if err := SnapshotDB(ctx, db, path); err != nil {
+				return err
+			}
+			if err := pruneBackups(dir, retention); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// pruneBackups removes the oldest backup-*.gz files in dir until at most
+// retention remain.
+func pruneBackups(dir string, retention int) error {
+	matches, err := filepath.Glob(filepath.Join(dir, "backup-*.gz"))
+	if err != nil {
+		return err
+	}
+	if len(matches) <= retention {
+		return nil
+	}
+
+	sort.Strings(matches)
+	for _, path := range matches[:len(matches)-retention] {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,520 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// dbVersion is the latest version of the pool database schema.
+const dbVersion = 4
+
+// postSnapshotFault, when non-nil, is invoked by upgradeDB immediately
+// after it snapshots the database and before it applies the migration for
+// the given target version. It exists solely so tests can exercise the
+// failure-injection path described in the upgrade harness; production code
+// never sets it.
+var postSnapshotFault func(targetVersion uint32) error
+
+var (
+	// poolBkt is the top level bucket housing all pool database state.
+	poolBkt = []byte("poolbkt")
+
+	// shareBkt stores submitted shares, keyed by their UUID.
+	shareBkt = []byte("sharebkt")
+
+	// paymentBkt stores pending payments, keyed by paymentID.
+	paymentBkt = []byte("paymentbkt")
+
+	// paymentArchiveBkt stores payments that have been paid out, keyed by
+	// paymentID.
+	paymentArchiveBkt = []byte("paymentarchivebkt")
+
+	// shareLegacyIndexBkt records, for every share re-keyed by upgradeToV2,
+	// a mapping from the share's UUID back to the sequential index key it
+	// held under the pre-V2 schema. downgradeToV1 consults it to restore
+	// the exact original key instead of minting a new one, since bbolt's
+	// per-bucket sequence counter never resets and would otherwise hand
+	// out keys the original database never had.
+	shareLegacyIndexBkt = []byte("sharelegacyindexbkt")
+
+	// versionKey is the poolBkt key the current schema version is stored
+	// under.
+	versionKey = []byte("dbversion")
+)
+
+// openDB opens the pool database file at the provided path, creating it
+// (along with the top level bucket) if it does not already exist.
+func openDB(dbPath string) (*bolt.DB, error) {
+	db, err := bolt.Open(dbPath, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		desc := fmt.Sprintf("unable to open db file %s", dbPath)
+		return nil, MakeError(ErrDBOpen, desc, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(poolBkt)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		desc := "unable to create top level bucket"
+		return nil, MakeError(ErrDBOpen, desc, err)
+	}
+
+	return db, nil
+}
+
+// fetchDBVersion returns the schema version recorded in the pool bucket, or
+// zero if none has been set yet (a database created before versioning was
+// tracked explicitly).
+func fetchDBVersion(tx *bolt.Tx) (uint32, error) {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return 0, MakeError(ErrBucketNotFound, desc, nil)
+	}
+
+	vBytes := pbkt.Get(versionKey)
+	if vBytes == nil {
+		return 0, nil
+	}
+
+	var version uint32
+	err := json.Unmarshal(vBytes, &version)
+	if err != nil {
+		desc := "unable to parse db version"
+		return 0, MakeError(ErrParse, desc, err)
+	}
+
+	return version, nil
+}
+
+// setDBVersion records the schema version in the pool bucket.
+func setDBVersion(tx *bolt.Tx, version uint32) error {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return MakeError(ErrBucketNotFound, desc, nil)
+	}
+
+	vBytes, err := json.Marshal(version)
+	if err != nil {
+		return MakeError(ErrParse, "unable to marshal db version", err)
+	}
+
+	return pbkt.Put(versionKey, vBytes)
+}
+
+// upgradeDB upgrades the provided database to the latest schema version,
+// applying each version transition in turn. If the database's recorded
+// version is newer than dbVersion, upgradeDB refuses to continue unless
+// allowDowngrade is set, since the running binary may not understand the
+// newer schema.
+func upgradeDB(db *bolt.DB, allowDowngrade bool) error {
+	var version uint32
+	err := db.View(func(tx *bolt.Tx) error {
+		v, err := fetchDBVersion(tx)
+		version = v
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if version > dbVersion && !allowDowngrade {
+		desc := fmt.Sprintf("db version %d is newer than the supported "+
+			"version %d, use --allow-downgrade to open it anyway", version, dbVersion)
+		return MakeError(ErrDBUpgrade, desc, nil)
+	}
+
+	upgrades := []struct {
+		version uint32
+		fn      func(*bolt.Tx) error
+	}{
+		{2, upgradeToV2},
+		{3, upgradeToV3},
+		{4, upgradeToV4},
+	}
+
+	for _, u := range upgrades {
+		if version >= u.version {
+			continue
+		}
+
+		snapshotPath := fmt.Sprintf("%s.preupgrade-v%d.gz", db.Path(), u.version)
+		if err := SnapshotDB(context.Background(), db, snapshotPath); err != nil {
+			desc := fmt.Sprintf("unable to snapshot db before upgrading to version %d", u.version)
+			return MakeError(ErrDBUpgrade, desc, err)
+		}
+
+		// postSnapshotFault lets tests abort an in-progress upgrade right
+		// after the pre-upgrade snapshot has been taken, to prove the
+		// snapshot survives and remains openable when the migration
+		// itself fails. It is always nil in production.
+		if postSnapshotFault != nil {
+			if err := postSnapshotFault(u.version); err != nil {
+				desc := fmt.Sprintf("upgrade to version %d aborted", u.version)
+				return MakeError(ErrDBUpgrade, desc, err)
+			}
+		}
+
+		err = db.Update(func(tx *bolt.Tx) error {
+			if err := u.fn(tx); err != nil {
+				return err
+			}
+			return setDBVersion(tx, u.version)
+		})
+		if err != nil {
+			desc := fmt.Sprintf("unable to upgrade db to version %d", u.version)
+			return MakeError(ErrDBUpgrade, desc, err)
+		}
+		version = u.version
+	}
+
+	return nil
+}
+
+// rekeyEntry describes a single key/value pair that needs to move to a new
+// key within the same bucket.
+type rekeyEntry struct {
+	oldKey []byte
+	newKey []byte
+	value  []byte
+}
+
+// applyRekeys deletes oldKey and writes value under newKey for every entry.
+// It must only be called once the bucket's cursor is no longer in use:
+// bbolt explicitly documents that mutating a bucket while a Cursor is
+// iterating it "may cause it to be invalidated and return unexpected keys
+// and/or values", so every re-keying upgrade/downgrade step collects the
+// entries it needs to move in a read-only pass first and only mutates the
+// bucket after that pass (here, or in downgradeToV1's own loop, which also
+// mints sequence numbers for shares with no recorded legacy key).
+func applyRekeys(bkt *bolt.Bucket, entries []rekeyEntry) error {
+	for _, e := range entries {
+		if err := bkt.Delete(e.oldKey); err != nil {
+			return err
+		}
+		if err := bkt.Put(e.newKey, e.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// upgradeToV2 re-keys the share bucket so that each share is keyed by its
+// UUID rather than a sequential index.
+func upgradeToV2(tx *bolt.Tx) error {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return MakeError(ErrBucketNotFound, desc, nil)
+	}
+
+	sbkt, err := pbkt.CreateBucketIfNotExists(shareBkt)
+	if err != nil {
+		return err
+	}
+	legacyBkt, err := pbkt.CreateBucketIfNotExists(shareLegacyIndexBkt)
+	if err != nil {
+		return err
+	}
+
+	var pending []rekeyEntry
+	c := sbkt.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var share Share
+		if err := json.Unmarshal(v, &share); err != nil {
+			return err
+		}
+		if string(k) == share.UUID {
+			continue
+		}
+		pending = append(pending, rekeyEntry{
+			oldKey: append([]byte(nil), k...),
+			newKey: []byte(share.UUID),
+			value:  append([]byte(nil), v...),
+		})
+	}
+
+	for _, e := range pending {
+		if err := legacyBkt.Put(e.newKey, e.oldKey); err != nil {
+			return err
+		}
+	}
+
+	return applyRekeys(sbkt, pending)
+}
+
+// upgradeToV3 adds a payment source to every payment and re-keys the
+// payment and payment archive buckets using paymentID.
+func upgradeToV3(tx *bolt.Tx) error {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return MakeError(ErrBucketNotFound, desc, nil)
+	}
+
+	for _, name := range [][]byte{paymentBkt, paymentArchiveBkt} {
+		bkt, err := pbkt.CreateBucketIfNotExists(name)
+		if err != nil {
+			return err
+		}
+
+		var pending []rekeyEntry
+		c := bkt.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var payment Payment
+			if err := json.Unmarshal(v, &payment); err != nil {
+				return err
+			}
+
+			if payment.Source == nil {
+				payment.Source = &PaymentSource{}
+			}
+
+			id := paymentID(payment.Height, payment.CreatedOn, payment.Account)
+			updated, err := json.Marshal(payment)
+			if err != nil {
+				return err
+			}
+			pending = append(pending, rekeyEntry{
+				oldKey: append([]byte(nil), k...),
+				newKey: id,
+				value:  updated,
+			})
+		}
+
+		if err := applyRekeys(bkt, pending); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upgradeToV4 drops the deprecated txfeereserve key.
+func upgradeToV4(tx *bolt.Tx) error {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return MakeError(ErrBucketNotFound, desc, nil)
+	}
+
+	return pbkt.Delete([]byte("txfeereserve"))
+}
+
+// BoltStore is a Store implementation backed by a local BoltDB file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (creating and upgrading it if necessary) the BoltDB
+// file at dbPath and returns a Store backed by it. allowDowngrade permits
+// opening a database whose recorded schema version is newer than the one
+// this binary understands.
+func NewBoltStore(dbPath string, allowDowngrade bool) (*BoltStore, error) {
+	db, err := openDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := upgradeDB(db, allowDowngrade); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStore{db: db}, nil
+}
+
+// Close releases all resources held by the store.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// GetShare fetches the share with the provided id.
+func (s *BoltStore) GetShare(uuid string) (*Share, error) {
+	var share Share
+	err := s.db.View(func(tx *bolt.Tx) error {
+		sbkt, err := fetchShareBkt(tx)
+		if err != nil {
+			return err
+		}
+		v := sbkt.Get([]byte(uuid))
+		if v == nil {
+			desc := fmt.Sprintf("no share found for id %s", uuid)
+			return MakeError(ErrValueNotFound, desc, nil)
+		}
+		return json.Unmarshal(v, &share)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// PutShare persists the provided share.
+func (s *BoltStore) PutShare(share *Share) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		sbkt, err := fetchShareBkt(tx)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(share)
+		if err != nil {
+			return MakeError(ErrParse, "unable to marshal share", err)
+		}
+		return sbkt.Put([]byte(share.UUID), v)
+	})
+}
+
+// IterateSharesSince calls fn for every share created on or after the
+// provided unix timestamp, in ascending order.
+func (s *BoltStore) IterateSharesSince(since int64, fn func(*Share) error) error {
+	var shares []*Share
+	err := s.db.View(func(tx *bolt.Tx) error {
+		sbkt, err := fetchShareBkt(tx)
+		if err != nil {
+			return err
+		}
+		c := sbkt.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var share Share
+			if err := json.Unmarshal(v, &share); err != nil {
+				return err
+			}
+			if share.CreatedOn < since {
+				continue
+			}
+			shares = append(shares, &share)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The bucket is keyed by UUID, so order by creation time explicitly.
+	sort.SliceStable(shares, func(i, j int) bool {
+		return shares[i].CreatedOn < shares[j].CreatedOn
+	})
+	for _, share := range shares {
+		if err := fn(share); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPayment fetches the payment with the provided id.
+func (s *BoltStore) GetPayment(id []byte) (*Payment, error) {
+	var payment Payment
+	err := s.db.View(func(tx *bolt.Tx) error {
+		pbkt, err := fetchPaymentBkt(tx)
+		if err != nil {
+			return err
+		}
+		v := pbkt.Get(id)
+		if v == nil {
+			desc := fmt.Sprintf("no payment found for id %x", id)
+			return MakeError(ErrValueNotFound, desc, nil)
+		}
+		return json.Unmarshal(v, &payment)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// PutPayment persists the provided payment as pending.
+func (s *BoltStore) PutPayment(payment *Payment) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		pbkt, err := fetchPaymentBkt(tx)
+		if err != nil {
+			return err
+		}
+		v, err := json.Marshal(payment)
+		if err != nil {
+			return MakeError(ErrParse, "unable to marshal payment", err)
+		}
+		id := paymentID(payment.Height, payment.CreatedOn, payment.Account)
+		return pbkt.Put(id, v)
+	})
+}
+
+// ArchivePayment moves the provided payment from the pending payment set to
+// the archived payment set.
+func (s *BoltStore) ArchivePayment(payment *Payment) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		pbkt, err := fetchPaymentBkt(tx)
+		if err != nil {
+			return err
+		}
+		abkt, err := fetchPaymentArchiveBkt(tx)
+		if err != nil {
+			return err
+		}
+
+		id := paymentID(payment.Height, payment.CreatedOn, payment.Account)
+		v, err := json.Marshal(payment)
+		if err != nil {
+			return MakeError(ErrParse, "unable to marshal payment", err)
+		}
+		if err := pbkt.Delete(id); err != nil {
+			return err
+		}
+		return abkt.Put(id, v)
+	})
+}
+
+func fetchShareBkt(tx *bolt.Tx) (*bolt.Bucket, error) {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return nil, MakeError(ErrBucketNotFound, desc, nil)
+	}
+	sbkt := pbkt.Bucket(shareBkt)
+	if sbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(shareBkt))
+		return nil, MakeError(ErrBucketNotFound, desc, nil)
+	}
+	return sbkt, nil
+}
+
+func fetchPaymentBkt(tx *bolt.Tx) (*bolt.Bucket, error) {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return nil, MakeError(ErrBucketNotFound, desc, nil)
+	}
+	bkt := pbkt.Bucket(paymentBkt)
+	if bkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(paymentBkt))
+		return nil, MakeError(ErrBucketNotFound, desc, nil)
+	}
+	return bkt, nil
+}
+
+func fetchPaymentArchiveBkt(tx *bolt.Tx) (*bolt.Bucket, error) {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return nil, MakeError(ErrBucketNotFound, desc, nil)
+	}
+	bkt := pbkt.Bucket(paymentArchiveBkt)
+	if bkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(paymentArchiveBkt))
+		return nil, MakeError(ErrBucketNotFound, desc, nil)
+	}
+	return bkt, nil
+}
@@ -0,0 +1,209 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// legacyPaymentID returns the payment key used prior to schema version 3,
+// which did not account for a payment's creation time.
+func legacyPaymentID(height uint32, account string) []byte {
+	id := make([]byte, 4+len(account))
+	binary.BigEndian.PutUint32(id[:4], height)
+	copy(id[4:], account)
+	return id
+}
+
+// downgradeDB unwinds the database from its current schema version down to
+// targetVersion, one version transition at a time. It is the inverse of
+// upgradeDB and allows an operator to roll back to an older dcrpool release
+// without restoring from backup.
+func downgradeDB(db *bolt.DB, targetVersion uint32) error {
+	var version uint32
+	err := db.View(func(tx *bolt.Tx) error {
+		v, err := fetchDBVersion(tx)
+		version = v
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if targetVersion > version {
+		desc := fmt.Sprintf("cannot downgrade db from version %d to a "+
+			"newer version %d", version, targetVersion)
+		return MakeError(ErrDBUpgrade, desc, nil)
+	}
+
+	downgrades := []struct {
+		from uint32
+		to   uint32
+		fn   func(*bolt.Tx) error
+	}{
+		{4, 3, downgradeToV3},
+		{3, 2, downgradeToV2},
+		{2, 1, downgradeToV1},
+	}
+
+	for _, d := range downgrades {
+		if version != d.from || d.to < targetVersion {
+			continue
+		}
+		err = db.Update(func(tx *bolt.Tx) error {
+			if err := d.fn(tx); err != nil {
+				return err
+			}
+			return setDBVersion(tx, d.to)
+		})
+		if err != nil {
+			desc := fmt.Sprintf("unable to downgrade db to version %d", d.to)
+			return MakeError(ErrDBUpgrade, desc, err)
+		}
+		version = d.to
+	}
+
+	return nil
+}
+
+// downgradeToV3 restores the txfeereserve key dropped by upgradeToV4, with
+// a zero reserve since the original value cannot be recovered.
+func downgradeToV3(tx *bolt.Tx) error {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return MakeError(ErrBucketNotFound, desc, nil)
+	}
+
+	reserve, err := json.Marshal(int64(0))
+	if err != nil {
+		return MakeError(ErrParse, "unable to marshal txfeereserve", err)
+	}
+
+	return pbkt.Put([]byte("txfeereserve"), reserve)
+}
+
+// downgradeToV2 strips the payment source added by upgradeToV3 and rewrites
+// the payment and payment archive buckets using the pre-V3 key schema.
+func downgradeToV2(tx *bolt.Tx) error {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return MakeError(ErrBucketNotFound, desc, nil)
+	}
+
+	for _, name := range [][]byte{paymentBkt, paymentArchiveBkt} {
+		bkt := pbkt.Bucket(name)
+		if bkt == nil {
+			desc := fmt.Sprintf("bucket %s not found", string(name))
+			return MakeError(ErrBucketNotFound, desc, nil)
+		}
+
+		var pending []rekeyEntry
+		c := bkt.Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var payment Payment
+			if err := json.Unmarshal(v, &payment); err != nil {
+				return err
+			}
+
+			// Encode without a source field at all, as pre-V3 payments
+			// were, rather than as "source":null.
+			updated, err := json.Marshal(struct {
+				Account       string `json:"account"`
+				Estimated     bool   `json:"estimated"`
+				Amount        int64  `json:"amount"`
+				Height        uint32 `json:"height"`
+				CreatedOn     int64  `json:"createdon"`
+				TransactionID string `json:"transactionid"`
+			}{payment.Account, payment.Estimated, payment.Amount,
+				payment.Height, payment.CreatedOn, payment.TransactionID})
+			if err != nil {
+				return err
+			}
+
+			pending = append(pending, rekeyEntry{
+				oldKey: append([]byte(nil), k...),
+				newKey: legacyPaymentID(payment.Height, payment.Account),
+				value:  updated,
+			})
+		}
+
+		if err := applyRekeys(bkt, pending); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// downgradeToV1 re-keys the share bucket back to the sequential index each
+// share held before upgradeToV2, undoing the UUID-based keying it
+// introduced. The original index is recovered from shareLegacyIndexBkt
+// (populated by upgradeToV2) rather than minted via sbkt.NextSequence,
+// since bbolt's per-bucket sequence counter is monotonic and never resets:
+// reassigning fresh sequence numbers here would hand shares keys the
+// original pre-V2 database never had, breaking byte-for-byte round trips.
+// A share with no recorded legacy index (one created after the V2 upgrade)
+// falls back to a freshly minted sequence number, since no original key
+// exists to restore.
+func downgradeToV1(tx *bolt.Tx) error {
+	pbkt := tx.Bucket(poolBkt)
+	if pbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+		return MakeError(ErrBucketNotFound, desc, nil)
+	}
+
+	sbkt := pbkt.Bucket(shareBkt)
+	if sbkt == nil {
+		desc := fmt.Sprintf("bucket %s not found", string(shareBkt))
+		return MakeError(ErrBucketNotFound, desc, nil)
+	}
+
+	legacyBkt := pbkt.Bucket(shareLegacyIndexBkt)
+
+	var pending []rekeyEntry
+	c := sbkt.Cursor()
+	for k, v := c.First(); k != nil; k, v = c.Next() {
+		var legacyKey []byte
+		if legacyBkt != nil {
+			if lk := legacyBkt.Get(k); lk != nil {
+				legacyKey = append([]byte(nil), lk...)
+			}
+		}
+		pending = append(pending, rekeyEntry{
+			oldKey: append([]byte(nil), k...),
+			newKey: legacyKey,
+			value:  append([]byte(nil), v...),
+		})
+	}
+
+	for _, e := range pending {
+		newKey := e.newKey
+		if newKey == nil {
+			seq, err := sbkt.NextSequence()
+			if err != nil {
+				return err
+			}
+			newKey = make([]byte, 8)
+			binary.BigEndian.PutUint64(newKey, seq)
+		}
+		if err := sbkt.Delete(e.oldKey); err != nil {
+			return err
+		}
+		if err := sbkt.Put(newKey, e.value); err != nil {
+			return err
+		}
+	}
+
+	if legacyBkt != nil {
+		return pbkt.DeleteBucket(shareLegacyIndexBkt)
+	}
+	return nil
+}
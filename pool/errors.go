@@ -0,0 +1,73 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import "errors"
+
+var (
+	// ErrBucketNotFound indicates a bucket could not be found in the pool
+	// database.
+	ErrBucketNotFound = errors.New("bucket not found")
+
+	// ErrValueNotFound indicates a key could not be found in a bucket.
+	ErrValueNotFound = errors.New("value not found")
+
+	// ErrParse indicates a parsing error, usually a failure to unmarshal a
+	// persisted value.
+	ErrParse = errors.New("parse error")
+
+	// ErrDBOpen indicates the pool database could not be opened.
+	ErrDBOpen = errors.New("unable to open database")
+
+	// ErrDBUpgrade indicates the pool database upgrade or downgrade process
+	// failed.
+	ErrDBUpgrade = errors.New("database upgrade failed")
+)
+
+// Error identifies an error relating to pool database operations. It wraps
+// one of the sentinel errors above so that callers can use errors.Is to
+// distinguish error conditions (e.g. a missing bucket from a decode
+// failure) without resorting to string matching, and errors.As to recover
+// the underlying cause when one is available.
+type Error struct {
+	// Description is a human readable summary of the error.
+	Description string
+
+	// Err is the sentinel this Error represents, one of the vars above.
+	Err error
+
+	// Cause is the underlying error that triggered this Error, if any.
+	Cause error
+}
+
+// Error satisfies the error interface.
+func (e Error) Error() string {
+	if e.Cause != nil {
+		return e.Description + ": " + e.Cause.Error()
+	}
+	return e.Description
+}
+
+// Unwrap returns both the sentinel e represents and, if set, its
+// underlying cause, so errors.Is(err, ErrBucketNotFound) and
+// errors.As(err, &someJSONError) both see through e to what they're
+// looking for. A nil Cause is omitted rather than returned as a literal
+// nil error.
+func (e Error) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Err}
+	}
+	return []error{e.Err, e.Cause}
+}
+
+// MakeError creates an Error which wraps the provided sentinel error and,
+// optionally, the underlying cause.
+func MakeError(sentinel error, desc string, cause error) Error {
+	return Error{
+		Description: desc,
+		Err:         sentinel,
+		Cause:       cause,
+	}
+}
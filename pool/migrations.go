@@ -0,0 +1,101 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+)
+
+//go:embed migrations/postgres/*.sql migrations/mysql/*.sql
+var migrationFS embed.FS
+
+// runMigrations applies every migration under migrations/<driver> that has
+// not already been recorded in the schema_migrations table, in filename
+// order.
+func runMigrations(db *sql.DB, driver string) error {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		filename VARCHAR(255) PRIMARY KEY
+	)`)
+	if err != nil {
+		desc := "unable to create schema_migrations table"
+		return MakeError(ErrDBUpgrade, desc, err)
+	}
+
+	entries, err := fs.ReadDir(migrationFS, fmt.Sprintf("migrations/%s", driver))
+	if err != nil {
+		desc := fmt.Sprintf("unable to read migrations for %s", driver)
+		return MakeError(ErrDBUpgrade, desc, err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	checkQuery := `SELECT COUNT(*) FROM schema_migrations WHERE filename = ?`
+	insertQuery := `INSERT INTO schema_migrations (filename) VALUES (?)`
+	if driver == "postgres" {
+		checkQuery = `SELECT COUNT(*) FROM schema_migrations WHERE filename = $1`
+		insertQuery = `INSERT INTO schema_migrations (filename) VALUES ($1)`
+	}
+
+	for _, name := range names {
+		var applied int
+		row := db.QueryRow(checkQuery, name)
+		if err := row.Scan(&applied); err != nil {
+			return err
+		}
+		if applied > 0 {
+			continue
+		}
+
+		path := fmt.Sprintf("migrations/%s/%s", driver, name)
+		stmt, err := migrationFS.ReadFile(path)
+		if err != nil {
+			desc := fmt.Sprintf("unable to read migration %s", name)
+			return MakeError(ErrDBUpgrade, desc, err)
+		}
+
+		// go-sql-driver/mysql refuses multiple statements per query
+		// unless the DSN opts in with multiStatements=true, which we
+		// don't require callers to set, so each statement in the
+		// migration file is executed individually rather than relying
+		// on the driver to split them.
+		for _, s := range splitSQLStatements(string(stmt)) {
+			if _, err := db.Exec(s); err != nil {
+				desc := fmt.Sprintf("unable to apply migration %s", name)
+				return MakeError(ErrDBUpgrade, desc, err)
+			}
+		}
+
+		if _, err := db.Exec(insertQuery, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitSQLStatements splits a migration file's contents into individual
+// statements on ";" boundaries. Migration files are plain DDL with no
+// string literals containing semicolons, so a simple split is sufficient.
+func splitSQLStatements(script string) []string {
+	raw := strings.Split(script, ";")
+	statements := make([]string, 0, len(raw))
+	for _, s := range raw {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		statements = append(statements, s)
+	}
+	return statements
+}
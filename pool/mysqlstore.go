@@ -0,0 +1,169 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+// MySQLStore is a Store implementation backed by a MySQL or MariaDB
+// database.
+type MySQLStore struct {
+	db *sql.DB
+}
+
+// NewMySQLStore opens a connection to the MySQL/MariaDB database described
+// by dsn and applies any outstanding migrations.
+func NewMySQLStore(dsn string) (*MySQLStore, error) {
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		desc := "unable to open mysql connection"
+		return nil, MakeError(ErrDBOpen, desc, err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		desc := "unable to reach mysql server"
+		return nil, MakeError(ErrDBOpen, desc, err)
+	}
+
+	if err := runMigrations(db, "mysql"); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &MySQLStore{db: db}, nil
+}
+
+// Close releases all resources held by the store.
+func (s *MySQLStore) Close() error {
+	return s.db.Close()
+}
+
+// GetShare fetches the share with the provided id.
+func (s *MySQLStore) GetShare(uuid string) (*Share, error) {
+	var share Share
+	row := s.db.QueryRow(
+		`SELECT uuid, account, weight, createdon FROM shares WHERE uuid = ?`, uuid)
+	err := row.Scan(&share.UUID, &share.Account, &share.Weight, &share.CreatedOn)
+	if errors.Is(err, sql.ErrNoRows) {
+		desc := fmt.Sprintf("no share found for id %s", uuid)
+		return nil, MakeError(ErrValueNotFound, desc, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// PutShare persists the provided share.
+func (s *MySQLStore) PutShare(share *Share) error {
+	_, err := s.db.Exec(
+		`INSERT INTO shares (uuid, account, weight, createdon) VALUES (?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE account = VALUES(account), weight = VALUES(weight),
+		 createdon = VALUES(createdon)`,
+		share.UUID, share.Account, share.Weight, share.CreatedOn)
+	return err
+}
+
+// IterateSharesSince calls fn for every share created on or after the
+// provided unix timestamp, in ascending order.
+func (s *MySQLStore) IterateSharesSince(since int64, fn func(*Share) error) error {
+	rows, err := s.db.Query(
+		`SELECT uuid, account, weight, createdon FROM shares
+		 WHERE createdon >= ? ORDER BY createdon ASC`, since)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var share Share
+		if err := rows.Scan(&share.UUID, &share.Account, &share.Weight, &share.CreatedOn); err != nil {
+			return err
+		}
+		if err := fn(&share); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetPayment fetches the payment with the provided id.
+func (s *MySQLStore) GetPayment(id []byte) (*Payment, error) {
+	var payment Payment
+	var source []byte
+	row := s.db.QueryRow(
+		`SELECT account, estimated, amount, height, createdon, source, transactionid
+		 FROM payments WHERE id = ?`, id)
+	err := row.Scan(&payment.Account, &payment.Estimated, &payment.Amount,
+		&payment.Height, &payment.CreatedOn, &source, &payment.TransactionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		desc := fmt.Sprintf("no payment found for id %x", id)
+		return nil, MakeError(ErrValueNotFound, desc, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(source) > 0 {
+		if err := json.Unmarshal(source, &payment.Source); err != nil {
+			return nil, MakeError(ErrParse, "unable to parse payment source", err)
+		}
+	}
+	return &payment, nil
+}
+
+// PutPayment persists the provided payment as pending.
+func (s *MySQLStore) PutPayment(payment *Payment) error {
+	id := paymentID(payment.Height, payment.CreatedOn, payment.Account)
+	source, err := json.Marshal(payment.Source)
+	if err != nil {
+		return MakeError(ErrParse, "unable to marshal payment source", err)
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO payments (id, account, estimated, amount, height, createdon, source, transactionid)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON DUPLICATE KEY UPDATE estimated = VALUES(estimated), amount = VALUES(amount),
+		 transactionid = VALUES(transactionid)`,
+		id, payment.Account, payment.Estimated, payment.Amount, payment.Height,
+		payment.CreatedOn, source, payment.TransactionID)
+	return err
+}
+
+// ArchivePayment moves the provided payment from the pending payment set to
+// the archived payment set.
+func (s *MySQLStore) ArchivePayment(payment *Payment) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id := paymentID(payment.Height, payment.CreatedOn, payment.Account)
+	source, err := json.Marshal(payment.Source)
+	if err != nil {
+		return MakeError(ErrParse, "unable to marshal payment source", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM payments WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO payments_archive (id, account, estimated, amount, height, createdon, source, transactionid)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		id, payment.Account, payment.Estimated, payment.Amount, payment.Height,
+		payment.CreatedOn, source, payment.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,31 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build integration
+
+package pool
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMySQLStore exercises MySQLStore (and, transitively, runMigrations
+// against the mysql driver) against a live server. It is gated behind the
+// "integration" build tag and skips unless DCRPOOL_TEST_MYSQL_DSN is set;
+// see docker-compose.yml at the repo root for a server to point it at.
+func TestMySQLStore(t *testing.T) {
+	dsn := os.Getenv("DCRPOOL_TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("DCRPOOL_TEST_MYSQL_DSN not set, skipping mysql integration test")
+	}
+
+	store, err := NewMySQLStore(dsn)
+	if err != nil {
+		t.Fatalf("NewMySQLStore: %v", err)
+	}
+	defer store.Close()
+
+	exerciseStore(t, store)
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"encoding/binary"
+)
+
+// PaymentSource represents the payment's source of funds.
+type PaymentSource struct {
+	BlockHash string `json:"blockhash"`
+	Coinbase  string `json:"coinbase"`
+}
+
+// Payment represents an outstanding payment for an account.
+type Payment struct {
+	Account       string         `json:"account"`
+	Estimated     bool           `json:"estimated"`
+	Amount        int64          `json:"amount"`
+	Height        uint32         `json:"height"`
+	CreatedOn     int64          `json:"createdon"`
+	Source        *PaymentSource `json:"source"`
+	TransactionID string         `json:"transactionid"`
+}
+
+// paymentID generates a unique key for a payment from its height, creation
+// time and account, matching the ordering used by the payment bucket cursor.
+func paymentID(height uint32, createdOn int64, account string) []byte {
+	id := make([]byte, 4+8+len(account))
+	binary.BigEndian.PutUint32(id[:4], height)
+	binary.BigEndian.PutUint64(id[4:12], uint64(createdOn))
+	copy(id[12:], account)
+	return id
+}
@@ -0,0 +1,174 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	_ "github.com/lib/pq"
+)
+
+// PostgresStore is a Store implementation backed by a Postgres database. It
+// is intended for multi-node pool deployments and managed cloud databases
+// where a file-locked BoltDB is not an option.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore opens a connection to the Postgres database described by
+// dsn and applies any outstanding migrations.
+func NewPostgresStore(dsn string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		desc := "unable to open postgres connection"
+		return nil, MakeError(ErrDBOpen, desc, err)
+	}
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		desc := "unable to reach postgres server"
+		return nil, MakeError(ErrDBOpen, desc, err)
+	}
+
+	if err := runMigrations(db, "postgres"); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresStore{db: db}, nil
+}
+
+// Close releases all resources held by the store.
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+// GetShare fetches the share with the provided id.
+func (s *PostgresStore) GetShare(uuid string) (*Share, error) {
+	var share Share
+	row := s.db.QueryRow(
+		`SELECT uuid, account, weight, createdon FROM shares WHERE uuid = $1`, uuid)
+	err := row.Scan(&share.UUID, &share.Account, &share.Weight, &share.CreatedOn)
+	if errors.Is(err, sql.ErrNoRows) {
+		desc := fmt.Sprintf("no share found for id %s", uuid)
+		return nil, MakeError(ErrValueNotFound, desc, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// PutShare persists the provided share.
+func (s *PostgresStore) PutShare(share *Share) error {
+	_, err := s.db.Exec(
+		`INSERT INTO shares (uuid, account, weight, createdon)
+		 VALUES ($1, $2, $3, $4)
+		 ON CONFLICT (uuid) DO UPDATE SET account = $2, weight = $3, createdon = $4`,
+		share.UUID, share.Account, share.Weight, share.CreatedOn)
+	return err
+}
+
+// IterateSharesSince calls fn for every share created on or after the
+// provided unix timestamp, in ascending order.
+func (s *PostgresStore) IterateSharesSince(since int64, fn func(*Share) error) error {
+	rows, err := s.db.Query(
+		`SELECT uuid, account, weight, createdon FROM shares
+		 WHERE createdon >= $1 ORDER BY createdon ASC`, since)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var share Share
+		if err := rows.Scan(&share.UUID, &share.Account, &share.Weight, &share.CreatedOn); err != nil {
+			return err
+		}
+		if err := fn(&share); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// GetPayment fetches the payment with the provided id.
+func (s *PostgresStore) GetPayment(id []byte) (*Payment, error) {
+	return s.getPaymentFrom("payments", id)
+}
+
+func (s *PostgresStore) getPaymentFrom(table string, id []byte) (*Payment, error) {
+	var payment Payment
+	var source []byte
+	query := fmt.Sprintf(
+		`SELECT account, estimated, amount, height, createdon, source, transactionid
+		 FROM %s WHERE id = $1`, table)
+	row := s.db.QueryRow(query, id)
+	err := row.Scan(&payment.Account, &payment.Estimated, &payment.Amount,
+		&payment.Height, &payment.CreatedOn, &source, &payment.TransactionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		desc := fmt.Sprintf("no payment found for id %x", id)
+		return nil, MakeError(ErrValueNotFound, desc, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(source) > 0 {
+		if err := json.Unmarshal(source, &payment.Source); err != nil {
+			return nil, MakeError(ErrParse, "unable to parse payment source", err)
+		}
+	}
+	return &payment, nil
+}
+
+// PutPayment persists the provided payment as pending.
+func (s *PostgresStore) PutPayment(payment *Payment) error {
+	id := paymentID(payment.Height, payment.CreatedOn, payment.Account)
+	source, err := json.Marshal(payment.Source)
+	if err != nil {
+		return MakeError(ErrParse, "unable to marshal payment source", err)
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO payments (id, account, estimated, amount, height, createdon, source, transactionid)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT (id) DO UPDATE SET estimated = $3, amount = $4, transactionid = $8`,
+		id, payment.Account, payment.Estimated, payment.Amount, payment.Height,
+		payment.CreatedOn, source, payment.TransactionID)
+	return err
+}
+
+// ArchivePayment moves the provided payment from the pending payment set to
+// the archived payment set.
+func (s *PostgresStore) ArchivePayment(payment *Payment) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	id := paymentID(payment.Height, payment.CreatedOn, payment.Account)
+	source, err := json.Marshal(payment.Source)
+	if err != nil {
+		return MakeError(ErrParse, "unable to marshal payment source", err)
+	}
+
+	if _, err := tx.Exec(`DELETE FROM payments WHERE id = $1`, id); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO payments_archive (id, account, estimated, amount, height, createdon, source, transactionid)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		id, payment.Account, payment.Estimated, payment.Amount, payment.Height,
+		payment.CreatedOn, source, payment.TransactionID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
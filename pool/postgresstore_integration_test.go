@@ -0,0 +1,32 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build integration
+
+package pool
+
+import (
+	"os"
+	"testing"
+)
+
+// TestPostgresStore exercises PostgresStore (and, transitively,
+// runMigrations against the postgres driver) against a live server. It is
+// gated behind the "integration" build tag and skips unless
+// DCRPOOL_TEST_POSTGRES_DSN is set; see docker-compose.yml at the repo root
+// for a server to point it at.
+func TestPostgresStore(t *testing.T) {
+	dsn := os.Getenv("DCRPOOL_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("DCRPOOL_TEST_POSTGRES_DSN not set, skipping postgres integration test")
+	}
+
+	store, err := NewPostgresStore(dsn)
+	if err != nil {
+		t.Fatalf("NewPostgresStore: %v", err)
+	}
+	defer store.Close()
+
+	exerciseStore(t, store)
+}
@@ -0,0 +1,13 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+// Share represents a client share submission to the pool.
+type Share struct {
+	UUID      string `json:"uuid"`
+	Account   string `json:"account"`
+	Weight    string `json:"weight"`
+	CreatedOn int64  `json:"createdon"`
+}
@@ -0,0 +1,83 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package pool
+
+import "fmt"
+
+// Backend identifies a supported pool database backend.
+type Backend string
+
+const (
+	// BoltBackend persists pool state to a local BoltDB file. It is the
+	// default backend and requires no external services.
+	BoltBackend Backend = "bolt"
+
+	// PostgresBackend persists pool state to a Postgres database.
+	PostgresBackend Backend = "postgres"
+
+	// MySQLBackend persists pool state to a MySQL/MariaDB database.
+	MySQLBackend Backend = "mysql"
+)
+
+// StoreConfig houses the information needed to open a pool Store.
+type StoreConfig struct {
+	// Backend is the storage backend to use.
+	Backend Backend
+
+	// DBPath is the path to the BoltDB file. Only used by the bolt backend.
+	DBPath string
+
+	// DSN is the data source name used to connect to a Postgres or MySQL
+	// database. Only used by the postgres and mysql backends.
+	DSN string
+
+	// AllowDowngrade permits opening a database whose recorded schema
+	// version is newer than the one this binary understands. Only used
+	// by the bolt backend.
+	AllowDowngrade bool
+}
+
+// Store defines the set of operations the pool needs from a persistence
+// backend. Implementations must be safe for concurrent use.
+type Store interface {
+	// Close releases all resources held by the store.
+	Close() error
+
+	// GetShare fetches the share with the provided id.
+	GetShare(uuid string) (*Share, error)
+
+	// PutShare persists the provided share.
+	PutShare(share *Share) error
+
+	// IterateSharesSince calls fn for every share created on or after the
+	// provided unix timestamp, in ascending order.
+	IterateSharesSince(since int64, fn func(*Share) error) error
+
+	// GetPayment fetches the payment with the provided id.
+	GetPayment(id []byte) (*Payment, error)
+
+	// PutPayment persists the provided payment as pending.
+	PutPayment(payment *Payment) error
+
+	// ArchivePayment moves the provided payment from the pending payment
+	// set to the archived payment set.
+	ArchivePayment(payment *Payment) error
+}
+
+// NewStore opens and returns a Store for the backend described by cfg,
+// upgrading it to the latest schema version if necessary.
+func NewStore(cfg *StoreConfig) (Store, error) {
+	switch cfg.Backend {
+	case BoltBackend, "":
+		return NewBoltStore(cfg.DBPath, cfg.AllowDowngrade)
+	case PostgresBackend:
+		return NewPostgresStore(cfg.DSN)
+	case MySQLBackend:
+		return NewMySQLStore(cfg.DSN)
+	default:
+		desc := fmt.Sprintf("unsupported db backend %q", cfg.Backend)
+		return nil, MakeError(ErrDBOpen, desc, nil)
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+//go:build integration
+
+package pool
+
+import (
+	"testing"
+	"time"
+)
+
+// exerciseStore runs the same CRUD round trip against any Store
+// implementation, so each backend-specific integration test only needs to
+// supply a connected Store.
+func exerciseStore(t *testing.T, store Store) {
+	t.Helper()
+
+	s := &Share{
+		UUID:      "integration-test-share",
+		Account:   "integration-test-account",
+		Weight:    "1.0",
+		CreatedOn: time.Now().Unix(),
+	}
+	if err := store.PutShare(s); err != nil {
+		t.Fatalf("PutShare: %v", err)
+	}
+	got, err := store.GetShare(s.UUID)
+	if err != nil {
+		t.Fatalf("GetShare: %v", err)
+	}
+	if got.UUID != s.UUID || got.Account != s.Account {
+		t.Fatalf("expected GetShare to return %+v, got %+v", s, got)
+	}
+
+	var seen bool
+	err = store.IterateSharesSince(s.CreatedOn, func(share *Share) error {
+		if share.UUID == s.UUID {
+			seen = true
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("IterateSharesSince: %v", err)
+	}
+	if !seen {
+		t.Fatalf("expected IterateSharesSince to visit %s", s.UUID)
+	}
+
+	p := &Payment{
+		Account:   "integration-test-account",
+		Amount:    1000,
+		Height:    12345,
+		CreatedOn: time.Now().Unix(),
+		Source:    &PaymentSource{BlockHash: "deadbeef", Coinbase: "coinbase-tx"},
+	}
+	if err := store.PutPayment(p); err != nil {
+		t.Fatalf("PutPayment: %v", err)
+	}
+	id := paymentID(p.Height, p.CreatedOn, p.Account)
+	gotPayment, err := store.GetPayment(id)
+	if err != nil {
+		t.Fatalf("GetPayment: %v", err)
+	}
+	if gotPayment.Account != p.Account || gotPayment.Amount != p.Amount {
+		t.Fatalf("expected GetPayment to return %+v, got %+v", p, gotPayment)
+	}
+
+	if err := store.ArchivePayment(p); err != nil {
+		t.Fatalf("ArchivePayment: %v", err)
+	}
+	if _, err := store.GetPayment(id); err == nil {
+		t.Fatal("expected GetPayment to fail for an archived payment")
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2021 The Decred developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Command gen writes the v1.db.gz and v2.db.gz fixtures consumed by
+// TestUpgrades and TestDowngrades in ../../upgrades_test.go to the testdata
+// directory. Run it with:
+//
+//	go run ./pool/testdata/gen
+//
+// whenever the pre-upgrade schemas it emits need to change.
+package main
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	poolBkt           = []byte("poolbkt")
+	shareBkt          = []byte("sharebkt")
+	paymentBkt        = []byte("paymentbkt")
+	paymentArchiveBkt = []byte("paymentarchivebkt")
+)
+
+type share struct {
+	UUID      string `json:"uuid"`
+	Account   string `json:"account"`
+	Weight    string `json:"weight"`
+	CreatedOn int64  `json:"createdon"`
+}
+
+type payment struct {
+	Account       string `json:"account"`
+	Estimated     bool   `json:"estimated"`
+	Amount        int64  `json:"amount"`
+	Height        uint32 `json:"height"`
+	CreatedOn     int64  `json:"createdon"`
+	TransactionID string `json:"transactionid"`
+}
+
+func main() {
+	dir, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	outDir := filepath.Join(dir, "pool", "testdata")
+
+	if err := genV1(filepath.Join(outDir, "v1.db.gz")); err != nil {
+		log.Fatalf("genV1: %v", err)
+	}
+	if err := genV2(filepath.Join(outDir, "v2.db.gz")); err != nil {
+		log.Fatalf("genV2: %v", err)
+	}
+}
+
+// genV1 writes a database at the pre-V2 schema: shares keyed by a
+// sequential index rather than their UUID.
+func genV1(path string) error {
+	return withTempDB(path, func(db *bolt.DB) error {
+		return db.Update(func(tx *bolt.Tx) error {
+			pbkt, err := tx.CreateBucketIfNotExists(poolBkt)
+			if err != nil {
+				return err
+			}
+			sbkt, err := pbkt.CreateBucketIfNotExists(shareBkt)
+			if err != nil {
+				return err
+			}
+
+			for i := 0; i < 5; i++ {
+				s := share{
+					UUID:      fakeUUID(i),
+					Account:   "account-1",
+					Weight:    "1.0",
+					CreatedOn: time.Now().Unix(),
+				}
+				v, err := json.Marshal(s)
+				if err != nil {
+					return err
+				}
+				seq, err := sbkt.NextSequence()
+				if err != nil {
+					return err
+				}
+				idx := make([]byte, 8)
+				binary.BigEndian.PutUint64(idx, seq)
+				if err := sbkt.Put(idx, v); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	})
+}
+
+// genV2 writes a database at the pre-V3 schema: payments keyed by
+// height+account, with no source and with a txfeereserve key still set.
+func genV2(path string) error {
+	return withTempDB(path, func(db *bolt.DB) error {
+		return db.Update(func(tx *bolt.Tx) error {
+			pbkt, err := tx.CreateBucketIfNotExists(poolBkt)
+			if err != nil {
+				return err
+			}
+			if _, err := pbkt.CreateBucketIfNotExists(shareBkt); err != nil {
+				return err
+			}
+			pmtBkt, err := pbkt.CreateBucketIfNotExists(paymentBkt)
+			if err != nil {
+				return err
+			}
+			archiveBkt, err := pbkt.CreateBucketIfNotExists(paymentArchiveBkt)
+			if err != nil {
+				return err
+			}
+
+			for i := 0; i < 3; i++ {
+				p := payment{
+					Account:   "account-1",
+					Amount:    100,
+					Height:    uint32(1000 + i),
+					CreatedOn: time.Now().Unix(),
+				}
+				v, err := json.Marshal(p)
+				if err != nil {
+					return err
+				}
+				id := legacyPaymentID(p.Height, p.Account)
+				if err := pmtBkt.Put(id, v); err != nil {
+					return err
+				}
+				if err := archiveBkt.Put(id, v); err != nil {
+					return err
+				}
+			}
+
+			reserve, err := json.Marshal(int64(5000))
+			if err != nil {
+				return err
+			}
+			return pbkt.Put([]byte("txfeereserve"), reserve)
+		})
+	})
+}
+
+func legacyPaymentID(height uint32, account string) []byte {
+	id := make([]byte, 4+len(account))
+	binary.BigEndian.PutUint32(id[:4], height)
+	copy(id[4:], account)
+	return id
+}
+
+func fakeUUID(i int) string {
+	return time.Unix(int64(i), 0).UTC().Format("20060102150405.000000000")
+}
+
+// withTempDB creates a fresh bolt database, lets fn populate it, then
+// gzip-compresses the resulting file to outPath.
+func withTempDB(outPath string, fn func(*bolt.DB) error) error {
+	tmp, err := os.CreateTemp("", "dcrpool_gen_*.db")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+
+	db, err := bolt.Open(tmpPath, 0600, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(db); err != nil {
+		db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return err
+	}
+
+	raw, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	gw := gzip.NewWriter(out)
+	if _, err := gw.Write(raw); err != nil {
+		return err
+	}
+	return gw.Close()
+}
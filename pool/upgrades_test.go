@@ -62,11 +62,224 @@ func TestUpgrades(t *testing.T) {
 					t.Fatal(err)
 				}
 				defer db.Close()
-				err = upgradeDB(db)
+				startVersion, err := currentDBVersion(db)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = upgradeDB(db, false)
 				if err != nil {
 					t.Fatalf("Upgrade failed: %v", err)
 				}
 				test.verify(t, db)
+				assertPreUpgradeSnapshots(t, dbPath, startVersion)
+			})
+		}
+	})
+
+	os.RemoveAll(d)
+}
+
+// TestUpgradeFailureInjection verifies that when a migration fails partway
+// through, the pre-upgrade snapshot upgradeDB took for that version is
+// still on disk and openable, so an operator can recover the pre-upgrade
+// state.
+func TestUpgradeFailureInjection(t *testing.T) {
+	d, err := ioutil.TempDir("", "dcrpool_test_upgrade_failure")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(d)
+
+	testFile, err := os.Open(filepath.Join("testdata", "v1.db.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer testFile.Close()
+	r, err := gzip.NewReader(testFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbPath := filepath.Join(d, "fault.db")
+	fi, err := os.Create(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = io.Copy(fi, r)
+	fi.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := openDB(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	injectedErr := fmt.Errorf("simulated failure applying version 2 migration")
+	postSnapshotFault = func(targetVersion uint32) error {
+		if targetVersion == 2 {
+			return injectedErr
+		}
+		return nil
+	}
+	defer func() { postSnapshotFault = nil }()
+
+	err = upgradeDB(db, false)
+	if err == nil {
+		t.Fatal("expected upgradeDB to fail due to the injected fault")
+	}
+
+	version, err := currentDBVersion(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != 0 {
+		t.Fatalf("expected db version to remain unchanged after a failed "+
+			"upgrade, got %d", version)
+	}
+
+	path := fmt.Sprintf("%s.preupgrade-v2.gz", dbPath)
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("expected preupgrade snapshot %s to exist after a failed "+
+			"upgrade: %v", path, err)
+	}
+	defer f.Close()
+
+	gr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatalf("expected preupgrade snapshot %s to be a valid gzip file: %v",
+			path, err)
+	}
+	if _, err := io.Copy(ioutil.Discard, gr); err != nil {
+		t.Fatalf("expected preupgrade snapshot %s to be readable: %v", path, err)
+	}
+}
+
+// currentDBVersion returns the schema version recorded in db.
+func currentDBVersion(db *bolt.DB) (uint32, error) {
+	var version uint32
+	err := db.View(func(tx *bolt.Tx) error {
+		v, err := fetchDBVersion(tx)
+		version = v
+		return err
+	})
+	return version, err
+}
+
+// assertPreUpgradeSnapshots checks that upgradeDB left behind an openable
+// preupgrade snapshot for every version transition applied on top of
+// startVersion. There is no V1 transition (it is a backwards-compatible
+// upgrade, see dbUpgradeTests above), so upgradeDB never snapshots for it;
+// the first possible snapshot is always v2.
+func assertPreUpgradeSnapshots(t *testing.T, dbPath string, startVersion uint32) {
+	t.Helper()
+
+	first := startVersion + 1
+	if first < 2 {
+		first = 2
+	}
+
+	for v := first; v <= dbVersion; v++ {
+		path := fmt.Sprintf("%s.preupgrade-v%d.gz", dbPath, v)
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("expected preupgrade snapshot %s to exist: %v", path, err)
+		}
+		defer f.Close()
+
+		r, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("expected preupgrade snapshot %s to be a valid gzip "+
+				"file: %v", path, err)
+		}
+		if _, err := io.Copy(ioutil.Discard, r); err != nil {
+			t.Fatalf("expected preupgrade snapshot %s to be readable: %v",
+				path, err)
+		}
+	}
+}
+
+// dbDowngradeTests mirrors dbUpgradeTests: each entry names a testdata file
+// recorded at the target version that a downgrade should land back on.
+var dbDowngradeTests = [...]struct {
+	targetVersion uint32
+	filename      string // in testdata directory
+}{
+	{1, "v1.db.gz"},
+	{2, "v2.db.gz"},
+}
+
+func TestDowngrades(t *testing.T) {
+	t.Parallel()
+
+	d, err := ioutil.TempDir("", "dcrpool_test_downgrades")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The subtests below run in parallel with each other, which means they
+	// don't actually execute until this enclosing "group" subtest's function
+	// returns (that's how t.Parallel pauses a test). t.Run("group") itself
+	// only returns once every subtest has finished, so the os.RemoveAll
+	// after it doesn't fire until every subtest is done with the temp dir.
+	t.Run("group", func(t *testing.T) {
+		for i, test := range dbDowngradeTests {
+			test := test
+			name := fmt.Sprintf("test%d", i)
+			t.Run(name, func(t *testing.T) {
+				t.Parallel()
+
+				testFile, err := os.Open(filepath.Join("testdata", test.filename))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer testFile.Close()
+				r, err := gzip.NewReader(testFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				dbPath := filepath.Join(d, name+".db")
+				fi, err := os.Create(dbPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				_, err = io.Copy(fi, r)
+				fi.Close()
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				db, err := openDB(dbPath)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer db.Close()
+
+				original, err := snapshotBuckets(db)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if err := upgradeDB(db, false); err != nil {
+					t.Fatalf("upgrade failed: %v", err)
+				}
+				if err := downgradeDB(db, test.targetVersion); err != nil {
+					t.Fatalf("downgrade failed: %v", err)
+				}
+
+				roundTripped, err := snapshotBuckets(db)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(original, roundTripped) {
+					t.Fatalf("expected bucket contents at version %d to match "+
+						"after an upgrade/downgrade round trip", test.targetVersion)
+				}
 			})
 		}
 	})
@@ -74,6 +287,43 @@ func TestUpgrades(t *testing.T) {
 	os.RemoveAll(d)
 }
 
+// snapshotBuckets returns a deterministic JSON encoding of every key/value
+// pair under poolBkt and its nested buckets, for use in round-trip
+// comparisons. It excludes the dbversion key and the txfeereserve key: the
+// latter's value is dropped by upgradeToV4 and downgradeToV3 restores it
+// with a zero reserve.
+func snapshotBuckets(db *bolt.DB) ([]byte, error) {
+	contents := make(map[string]string)
+	err := db.View(func(tx *bolt.Tx) error {
+		pbkt := tx.Bucket(poolBkt)
+		if pbkt == nil {
+			desc := fmt.Sprintf("bucket %s not found", string(poolBkt))
+			return MakeError(ErrBucketNotFound, desc, nil)
+		}
+		return walkBucket("", pbkt, contents)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(contents)
+}
+
+// walkBucket recursively records every key/value pair in bkt (and its
+// nested buckets) into contents, prefixing keys with path.
+func walkBucket(path string, bkt *bolt.Bucket, contents map[string]string) error {
+	return bkt.ForEach(func(k, v []byte) error {
+		if path == "" && (string(k) == string(versionKey) || string(k) == "txfeereserve") {
+			return nil
+		}
+		key := path + "/" + string(k)
+		if v != nil {
+			contents[key] = string(v)
+			return nil
+		}
+		return walkBucket(key, bkt.Bucket(k), contents)
+	})
+}
+
 func verifyV2Upgrade(t *testing.T, db *bolt.DB) {
 	err := db.View(func(tx *bolt.Tx) error {
 		pbkt := tx.Bucket(poolBkt)